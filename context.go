@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// ContextExtractor pulls correlation fields (trace ID, span ID, request
+// ID, ...) out of a context.Context for attachment to every record
+// written through OutputContext or one of the *Ctx methods.
+type ContextExtractor func(ctx context.Context) []KV
+
+// contextExtractorValue holds the registered ContextExtractor, boxed so
+// it can be stored in the atomic.Value below: atomic.Value requires every
+// Store to use the same concrete type, which a bare func value (possibly
+// nil) can't guarantee.
+type contextExtractorValue struct {
+	fn ContextExtractor
+}
+
+var contextExtractor atomic.Value // of contextExtractorValue
+
+// RegisterContextExtractor installs the ContextExtractor used by
+// OutputContext and the *Ctx methods, replacing any previously
+// registered one. It may be called concurrently with logging. A nil
+// extractor (the default) attaches no fields.
+func RegisterContextExtractor(e ContextExtractor) {
+	contextExtractor.Store(contextExtractorValue{fn: e})
+}
+
+// currentContextExtractor returns the registered ContextExtractor, or nil
+// if none has been registered.
+func currentContextExtractor() ContextExtractor {
+	v, ok := contextExtractor.Load().(contextExtractorValue)
+	if !ok {
+		return nil
+	}
+	return v.fn
+}
+
+// OutputContext is like Output, but additionally appends the fields
+// produced by the registered ContextExtractor for ctx, in the order it
+// returned them, after any fields already bound by With.
+func (l *Logger) OutputContext(ctx context.Context, calldepth, level int, s string) error {
+	fields := l.fields
+	if extract := currentContextExtractor(); extract != nil {
+		if extra := extract(ctx); len(extra) > 0 {
+			fields = append(append([]KV(nil), fields...), extra...)
+		}
+	}
+	return l.output(calldepth+1, level, s, fields)
+}
+
+// DebugCtx calls l.OutputContext at DEBUG level. Arguments are handled in
+// the manner of fmt.Print.
+func (l *Logger) DebugCtx(ctx context.Context, v ...interface{}) {
+	l.OutputContext(ctx, 2, DEBUG, fmt.Sprint(v...))
+}
+
+// InfoCtx calls l.OutputContext at INFO level. Arguments are handled in
+// the manner of fmt.Print.
+func (l *Logger) InfoCtx(ctx context.Context, v ...interface{}) {
+	l.OutputContext(ctx, 2, INFO, fmt.Sprint(v...))
+}
+
+// WarnCtx calls l.OutputContext at WARNING level. Arguments are handled
+// in the manner of fmt.Print.
+func (l *Logger) WarnCtx(ctx context.Context, v ...interface{}) {
+	l.OutputContext(ctx, 2, WARNING, fmt.Sprint(v...))
+}
+
+// ErrorCtx calls l.OutputContext at ERROR level. Arguments are handled in
+// the manner of fmt.Print.
+func (l *Logger) ErrorCtx(ctx context.Context, v ...interface{}) {
+	l.OutputContext(ctx, 2, ERROR, fmt.Sprint(v...))
+}
+
+// loggerContextKey is the unexported key NewContext stores a *Logger
+// under, so only this package can write or read it.
+type loggerContextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, retrievable with
+// FromContext, so library code can propagate a logger (including any
+// fields bound by With) through a call chain without threading it as a
+// separate parameter.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// FromContext returns the Logger attached to ctx by NewContext, or the
+// standard logger if ctx carries none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok {
+		return l
+	}
+	return std
+}
@@ -11,10 +11,12 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +28,7 @@ const (
 	INFO
 	WARNING
 	ERROR
+	FATAL
 )
 
 // These flags define which text to prefix to each log entry generated by the Logger.
@@ -42,19 +45,22 @@ const (
 	LstdFlags     = Ldate | Ltime // initial values for the standard logger
 )
 
-var levelChar = [4]byte{'D', 'I', 'W', 'E'}
+var levelChar = [5]byte{'D', 'I', 'W', 'E', 'F'}
 
 // A Logger represents an active logging object that generates lines of
 // output to an io.Writer.  Each logging operation makes a single call to
 // the Writer's Write method.  A Logger can be used simultaneously from
 // multiple goroutines; it guarantees to serialize access to the Writer.
 type Logger struct {
-	mu     sync.Mutex // ensures atomic writes; protects the following fields
-	prefix string     // prefix to write at beginning of each line
-	flag   int        // properties
-	level  int        // verbosity level
-	out    io.Writer  // destination for output
-	buf    []byte     // for accumulating text to write
+	mu        sync.Mutex // ensures atomic writes; protects the following fields
+	prefix    string     // prefix to write at beginning of each line
+	flag      int        // properties
+	level     int        // verbosity level
+	out       io.Writer  // destination for output
+	buf       []byte     // for accumulating text to write
+	formatter Formatter  // renders a Record into buf
+	fields    []KV       // key/value pairs bound by With, carried on every record
+	sampler   Sampler    // optional per-call-site filter, consulted before formatting
 }
 
 // New creates a new Logger.   The out variable sets the
@@ -64,13 +70,169 @@ type Logger struct {
 func New(out io.Writer, prefix string, level int) *Logger {
 
 	return &Logger{
-		out:    out,
-		prefix: prefix,
-		flag:   LstdFlags,
-		level:  level,
+		out:       out,
+		prefix:    prefix,
+		flag:      LstdFlags,
+		level:     level,
+		formatter: TextFormatter{},
 	}
 }
 
+// KV is a single structured key/value pair attached to a log record by
+// With or one of the *KV level methods.
+type KV struct {
+	Key string
+	Val interface{}
+}
+
+// Record carries everything needed to render one log line. It is built by
+// the shared output path and handed to the Logger's Formatter, so both the
+// legacy Output path and the structured *KV methods render from the same
+// data.
+type Record struct {
+	Time   time.Time
+	File   string
+	Line   int
+	Level  int
+	Prefix string
+	Flag   int
+	Msg    string
+	Fields []KV
+}
+
+// Formatter renders a Record, appending the result (including the
+// trailing newline) to buf.
+type Formatter interface {
+	Format(buf *[]byte, r *Record)
+}
+
+// TextFormatter renders a Record in this package's traditional
+// "[L] message k=v k=v" line format, built on top of formatHeader.
+type TextFormatter struct{}
+
+// Format implements Formatter.
+func (TextFormatter) Format(buf *[]byte, r *Record) {
+	formatHeader(buf, r.Time, r.File, r.Prefix, r.Line, r.Level, r.Flag)
+	*buf = append(*buf, r.Msg...)
+	for _, kv := range r.Fields {
+		*buf = append(*buf, ' ')
+		*buf = append(*buf, kv.Key...)
+		*buf = append(*buf, '=')
+		*buf = appendKVValue(*buf, kv.Val)
+	}
+	if len(*buf) == 0 || (*buf)[len(*buf)-1] != '\n' {
+		*buf = append(*buf, '\n')
+	}
+}
+
+// appendKVValue appends the textual representation of v used by
+// TextFormatter, quoting strings that contain a space, tab, line break,
+// double quote or '=' so the line stays easy to tokenize.
+func appendKVValue(buf []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case string:
+		if strings.ContainsAny(val, " \t\r\n\"=") {
+			return strconv.AppendQuote(buf, val)
+		}
+		return append(buf, val...)
+	case error:
+		return appendKVValue(buf, val.Error())
+	default:
+		return append(buf, fmt.Sprint(val)...)
+	}
+}
+
+// JSONFormatter renders a Record as a single-line JSON object with
+// "level" and "msg" keys, a "time" key when a date/time flag is set, a
+// "file" key when Lshortfile or Llongfile is set, a "prefix" key when the
+// prefix is non-empty, plus one key per field. A field whose key matches
+// one of these replaces it.
+type JSONFormatter struct{}
+
+// Format implements Formatter.
+func (JSONFormatter) Format(buf *[]byte, r *Record) {
+	obj := make(map[string]interface{}, 4+len(r.Fields))
+	if r.Flag&(Ldate|Ltime|Lmicroseconds) != 0 {
+		obj["time"] = r.Time.Format(time.RFC3339Nano)
+	}
+	obj["level"] = levelString(r.Level)
+	if r.Flag&(Lshortfile|Llongfile) != 0 {
+		obj["file"] = fmt.Sprintf("%s:%d", shortenFile(r.File, r.Flag), r.Line)
+	}
+	if r.Prefix != "" {
+		obj["prefix"] = r.Prefix
+	}
+	obj["msg"] = r.Msg
+	for _, kv := range r.Fields {
+		obj[kv.Key] = kv.Val
+	}
+
+	enc, err := json.Marshal(obj)
+	if err != nil {
+		enc = []byte(fmt.Sprintf(`{"level":"ERROR","msg":%q}`, "log: failed to marshal record: "+err.Error()))
+	}
+	*buf = append(*buf, enc...)
+	*buf = append(*buf, '\n')
+}
+
+// parseKV converts a flat key/value argument list, as accepted by With and
+// the *KV methods, into KV pairs. Malformed input is tolerated rather than
+// panicking: a trailing key with no value is logged as the value of the key
+// "!BADKEY", as in log/slog, and a non-string key is replaced by "!BADKEY"
+// while its paired value is kept.
+func parseKV(args []interface{}) []KV {
+	var kvs []KV
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			kvs = append(kvs, KV{Key: "!BADKEY", Val: args[i]})
+			break
+		}
+		key, ok := args[i].(string)
+		if !ok {
+			key = "!BADKEY"
+		}
+		kvs = append(kvs, KV{Key: key, Val: args[i+1]})
+	}
+	return kvs
+}
+
+// With returns a child Logger that shares l's destination, prefix, flags,
+// level and Formatter, but attaches args (an alternating key/value list,
+// as accepted by the *KV methods) to every record it writes.
+func (l *Logger) With(args ...interface{}) *Logger {
+	l.mu.Lock()
+	child := &Logger{
+		out:       l.out,
+		prefix:    l.prefix,
+		flag:      l.flag,
+		level:     l.level,
+		formatter: l.formatter,
+		sampler:   l.sampler,
+	}
+	base := l.fields
+	l.mu.Unlock()
+
+	child.fields = append(append([]KV(nil), base...), parseKV(args)...)
+	return child
+}
+
+// SetFormatter sets the Formatter used to render records written through
+// l, including those from the legacy Output path.
+func (l *Logger) SetFormatter(f Formatter) {
+	l.mu.Lock()
+	l.formatter = f
+	l.mu.Unlock()
+}
+
+// SetSampler attaches s to l; every subsequent record is passed to
+// s.Allow before being formatted and written, and is dropped if it
+// returns false. A nil Sampler (the default) allows everything.
+func (l *Logger) SetSampler(s Sampler) {
+	l.mu.Lock()
+	l.sampler = s
+	l.mu.Unlock()
+}
+
 var std = New(os.Stderr, "", DEBUG)
 
 // Cheap integer to fixed-width decimal ASCII.  Give a negative width to avoid zero-padding.
@@ -119,16 +281,7 @@ func formatHeader(buf *[]byte, t time.Time,
 	}
 
 	if flag&(Lshortfile|Llongfile) != 0 {
-		if flag&Lshortfile != 0 {
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			file = short
-		}
+		file = shortenFile(file, flag)
 		*buf = append(*buf, file...)
 		*buf = append(*buf, ':')
 		itoa(buf, line, -1)
@@ -143,15 +296,48 @@ func formatHeader(buf *[]byte, t time.Time,
 	*buf = append(*buf, '[', levelChar[level], ']', ' ')
 }
 
-// Output writes the output for a logging event.  The string s contains
-// the text to print after the prefix specified by the flags of the
-// Logger.  A newline is appended if the last character of s is not
-// already a newline.  Calldepth is used to recover the PC and is
-// provided for generality, although at the moment on all pre-defined
-// paths it will be 2.
-func (l *Logger) Output(calldepth, level int, s string) error {
+// shortenFile trims file down to its final path element when flag requests
+// Lshortfile; it is shared by formatHeader and JSONFormatter so both agree
+// on what "short" means.
+func shortenFile(file string, flag int) string {
+	if flag&Lshortfile == 0 {
+		return file
+	}
+	short := file
+	for i := len(file) - 1; i > 0; i-- {
+		if file[i] == '/' {
+			short = file[i+1:]
+			break
+		}
+	}
+	return short
+}
+
+// levelString returns the name of level, or "UNKNOWN" if it is not one of
+// the predefined level constants.
+func levelString(level int) string {
+	switch level {
+	case DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARNING:
+		return "WARNING"
+	case ERROR:
+		return "ERROR"
+	case FATAL:
+		return "FATAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// output captures the time and (if configured) the caller, builds a
+// Record and hands it to l.formatter under l.mu, then writes the result.
+// It is the shared path behind both Output and the *KV methods.
+func (l *Logger) output(calldepth, level int, msg string, fields []KV) error {
 
-	if level > ERROR || level < 0 {
+	if level > FATAL || level < 0 {
 		return fmt.Errorf("Unknown level %d", level)
 	}
 
@@ -167,7 +353,11 @@ func (l *Logger) Output(calldepth, level int, s string) error {
 		return nil
 	}
 
-	if l.flag&(Lshortfile|Llongfile) != 0 {
+	// A Sampler needs file:line to key on, even if Lshortfile/Llongfile
+	// isn't set, so it can decide before we format or write anything.
+	// Looking it up here means formatHeader reuses this result instead
+	// of calling runtime.Caller a second time.
+	if l.flag&(Lshortfile|Llongfile) != 0 || l.sampler != nil {
 
 		// release lock while getting caller info - it's expensive.
 		l.mu.Unlock()
@@ -179,16 +369,37 @@ func (l *Logger) Output(calldepth, level int, s string) error {
 		}
 		l.mu.Lock()
 	}
+
+	if l.sampler != nil && !l.sampler.Allow(level, file, line) {
+		return nil
+	}
+
 	l.buf = l.buf[:0]
-	formatHeader(&l.buf, now, file, l.prefix, line, level, l.flag)
-	l.buf = append(l.buf, s...)
-	if len(s) > 0 && s[len(s)-1] != '\n' {
-		l.buf = append(l.buf, '\n')
+	r := Record{
+		Time:   now,
+		File:   file,
+		Line:   line,
+		Level:  level,
+		Prefix: l.prefix,
+		Flag:   l.flag,
+		Msg:    msg,
+		Fields: fields,
 	}
+	l.formatter.Format(&l.buf, &r)
 	_, err := l.out.Write(l.buf)
 	return err
 }
 
+// Output writes the output for a logging event.  The string s contains
+// the text to print after the prefix specified by the flags of the
+// Logger.  A newline is appended if the last character of s is not
+// already a newline.  Calldepth is used to recover the PC and is
+// provided for generality, although at the moment on all pre-defined
+// paths it will be 2.
+func (l *Logger) Output(calldepth, level int, s string) error {
+	return l.output(calldepth+1, level, s, l.fields)
+}
+
 // Debug calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Debug(v ...interface{}) {
@@ -207,6 +418,13 @@ func (l *Logger) Debugln(v ...interface{}) {
 	l.Output(2, DEBUG, fmt.Sprintln(v...))
 }
 
+// DebugKV writes a structured record at DEBUG level. args is an
+// alternating list of keys and values, combined with any fields bound by
+// With, and rendered by l's Formatter.
+func (l *Logger) DebugKV(msg string, args ...interface{}) {
+	l.output(2, DEBUG, msg, append(append([]KV(nil), l.fields...), parseKV(args)...))
+}
+
 // Info calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Info(v ...interface{}) {
@@ -225,6 +443,13 @@ func (l *Logger) Infoln(v ...interface{}) {
 	l.Output(2, INFO, fmt.Sprintln(v...))
 }
 
+// InfoKV writes a structured record at INFO level. args is an alternating
+// list of keys and values, combined with any fields bound by With, and
+// rendered by l's Formatter.
+func (l *Logger) InfoKV(msg string, args ...interface{}) {
+	l.output(2, INFO, msg, append(append([]KV(nil), l.fields...), parseKV(args)...))
+}
+
 // Warn calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Warn(v ...interface{}) {
@@ -243,6 +468,13 @@ func (l *Logger) Warnln(v ...interface{}) {
 	l.Output(2, WARNING, fmt.Sprintln(v...))
 }
 
+// WarnKV writes a structured record at WARNING level. args is an
+// alternating list of keys and values, combined with any fields bound by
+// With, and rendered by l's Formatter.
+func (l *Logger) WarnKV(msg string, args ...interface{}) {
+	l.output(2, WARNING, msg, append(append([]KV(nil), l.fields...), parseKV(args)...))
+}
+
 // Error calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Error(v ...interface{}) {
@@ -261,6 +493,58 @@ func (l *Logger) Errorln(v ...interface{}) {
 	l.Output(2, ERROR, fmt.Sprintln(v...))
 }
 
+// ErrorKV writes a structured record at ERROR level. args is an
+// alternating list of keys and values, combined with any fields bound by
+// With, and rendered by l's Formatter.
+func (l *Logger) ErrorKV(msg string, args ...interface{}) {
+	l.output(2, ERROR, msg, append(append([]KV(nil), l.fields...), parseKV(args)...))
+}
+
+// Fatal calls l.Output at FATAL level, then os.Exit(1).
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Fatal(v ...interface{}) {
+	l.Output(2, FATAL, fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+// Fatalf calls l.Output at FATAL level, then os.Exit(1).
+// Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.Output(2, FATAL, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// Fatalln calls l.Output at FATAL level, then os.Exit(1).
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.Output(2, FATAL, fmt.Sprintln(v...))
+	os.Exit(1)
+}
+
+// Panic calls l.Output at FATAL level, then panics with the message.
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	l.Output(2, FATAL, s)
+	panic(s)
+}
+
+// Panicf calls l.Output at FATAL level, then panics with the message.
+// Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	l.Output(2, FATAL, s)
+	panic(s)
+}
+
+// Panicln calls l.Output at FATAL level, then panics with the message.
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	l.Output(2, FATAL, s)
+	panic(s)
+}
+
 // Flags returns the output flags for the logger.
 func (l *Logger) Flags() int {
 	l.mu.Lock()
@@ -298,19 +582,7 @@ func (l *Logger) Level() int {
 func (l *Logger) LevelString() string {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-
-	switch l.level {
-	case DEBUG:
-		return "DEBUG"
-	case ERROR:
-		return "ERROR"
-	case INFO:
-		return "INFO"
-	case WARNING:
-		return "WARNING"
-	default:
-		return "UNKNOWN"
-	}
+	return levelString(l.level)
 }
 
 func (l *Logger) SetLevel(level int) {
@@ -329,6 +601,8 @@ func (l *Logger) SetLevelString(level string) {
 		lvl = DEBUG
 	case "ERROR":
 		lvl = ERROR
+	case "FATAL":
+		lvl = FATAL
 	case "INFO":
 		lvl = INFO
 	case "WARNING":
@@ -342,11 +616,21 @@ func (l *Logger) SetLevelString(level string) {
 	l.mu.Unlock()
 }
 
+// SetOutput sets the output destination for l.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	l.out = w
+	l.mu.Unlock()
+}
+
 // SetOutput sets the output destination for the standard logger.
 func SetOutput(w io.Writer) {
-	std.mu.Lock()
-	defer std.mu.Unlock()
-	std.out = w
+	std.SetOutput(w)
+}
+
+// SetFormatter sets the Formatter used by the standard logger.
+func SetFormatter(f Formatter) {
+	std.SetFormatter(f)
 }
 
 // Flags returns the output flags for the standard logger.
@@ -450,3 +734,48 @@ func Errorf(format string, v ...interface{}) {
 func Errorln(v ...interface{}) {
 	std.Output(2, ERROR, fmt.Sprintln(v...))
 }
+
+// Fatal writes to the standard logger at FATAL level, then calls os.Exit(1).
+// Arguments are handled in the manner of fmt.Print.
+func Fatal(v ...interface{}) {
+	std.Output(2, FATAL, fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+// Fatalf writes to the standard logger at FATAL level, then calls os.Exit(1).
+// Arguments are handled in the manner of fmt.Printf.
+func Fatalf(format string, v ...interface{}) {
+	std.Output(2, FATAL, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// Fatalln writes to the standard logger at FATAL level, then calls os.Exit(1).
+// Arguments are handled in the manner of fmt.Println.
+func Fatalln(v ...interface{}) {
+	std.Output(2, FATAL, fmt.Sprintln(v...))
+	os.Exit(1)
+}
+
+// Panic writes to the standard logger at FATAL level, then panics with the
+// message. Arguments are handled in the manner of fmt.Print.
+func Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	std.Output(2, FATAL, s)
+	panic(s)
+}
+
+// Panicf writes to the standard logger at FATAL level, then panics with the
+// message. Arguments are handled in the manner of fmt.Printf.
+func Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	std.Output(2, FATAL, s)
+	panic(s)
+}
+
+// Panicln writes to the standard logger at FATAL level, then panics with the
+// message. Arguments are handled in the manner of fmt.Println.
+func Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	std.Output(2, FATAL, s)
+	panic(s)
+}
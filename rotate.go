@@ -0,0 +1,394 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// errRotatingFileClosed is returned by writes made after Close.
+var errRotatingFileClosed = errors.New("logger: write to closed RotatingFile")
+
+// RotationInterval selects a time-based rotation boundary for RotatingFile.
+type RotationInterval int
+
+const (
+	// NoInterval disables time-based rotation; only MaxSize and SIGHUP
+	// (if watched) trigger a rotation.
+	NoInterval RotationInterval = iota
+	// Hourly rotates the file the first time it is written to after the
+	// top of the hour.
+	Hourly
+	// Daily rotates the file the first time it is written to after
+	// midnight, local time.
+	Daily
+)
+
+// RotatingFile is an io.Writer that writes to a file on disk, rotating it
+// to a timestamped sibling once it exceeds MaxSize bytes or crosses an
+// Interval boundary. It can be passed to New or (*Logger).SetOutput.
+//
+// Rotation is serialized by a mutex private to the RotatingFile, so it
+// never contends with a Logger's own mutex: a stalled rename/reopen only
+// blocks writers going through this RotatingFile, not unrelated loggers
+// sharing the process.
+type RotatingFile struct {
+	// Path is the file that is actively written to; rotated segments are
+	// renamed alongside it with a timestamp suffix, e.g.
+	// "app.log.20060102T150405.000000000".
+	Path string
+	// MaxSize rotates the file once a write would push it past this many
+	// bytes. Zero disables size-based rotation.
+	MaxSize int64
+	// Interval rotates the file on the given time boundary. NoInterval
+	// disables time-based rotation.
+	Interval RotationInterval
+	// MaxBackups is the number of rotated segments to retain; the oldest
+	// are removed once there are more. Zero keeps all of them.
+	MaxBackups int
+	// Compress gzips each rotated segment in the background once it has
+	// been renamed out of the way.
+	Compress bool
+	// QueueSize, if non-zero, makes Write asynchronous: writes are
+	// queued on a bounded channel and handled by a background goroutine,
+	// with writes dropped (counted by DroppedCount) once the queue is
+	// full, instead of waiting for room. Write still takes mu, so it
+	// waits while the background goroutine holds mu to write a queued
+	// record or rotate the file.
+	QueueSize int
+
+	mu       sync.Mutex // serializes rename/reopen; independent of any Logger's mu
+	f        *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool // set by Close, under mu; rejects new writes but not the final queue drain
+
+	queue     chan []byte   // lazily created under mu; non-nil once queued mode has started
+	closing   chan struct{} // closed by Close to tell the drain goroutine to flush and exit
+	queueDone chan struct{} // closed by the drain goroutine once it has exited
+	dropped   uint64        // atomic
+
+	hup     chan os.Signal
+	hupOnce sync.Once
+}
+
+// NewRotatingFile returns a RotatingFile that writes to path. MaxSize,
+// Interval, MaxBackups, Compress and QueueSize may be set on the result
+// before it is first written to; the file itself is opened lazily on the
+// first Write.
+func NewRotatingFile(path string) *RotatingFile {
+	return &RotatingFile{Path: path}
+}
+
+// WatchSIGHUP starts watching SIGHUP and rotates the file each time it is
+// received, the same way many Unix daemons reopen their log file so an
+// external log-rotation tool can move it out from under them. It is
+// opt-in: a RotatingFile does not touch process-wide signal handling
+// unless this is called. Returns w for chaining with NewRotatingFile.
+func (w *RotatingFile) WatchSIGHUP() *RotatingFile {
+	w.hupOnce.Do(func() {
+		w.hup = make(chan os.Signal, 1)
+		signal.Notify(w.hup, syscall.SIGHUP)
+		go func() {
+			for range w.hup {
+				w.mu.Lock()
+				w.rotateLocked()
+				w.mu.Unlock()
+			}
+		}()
+	})
+	return w
+}
+
+// Write implements io.Writer. If QueueSize is zero it writes through
+// synchronously, rotating first if needed. If QueueSize is non-zero, p is
+// copied onto a bounded channel drained by a background goroutine, or
+// dropped if that channel is full; Write does not wait for the file write
+// or for room in the channel, but it does take w's mutex, so it waits
+// while the drain goroutine is writing a queued record or rotating.
+func (w *RotatingFile) Write(p []byte) (int, error) {
+	if w.QueueSize > 0 {
+		return w.writeQueued(p)
+	}
+	return w.writeSync(p)
+}
+
+func (w *RotatingFile) writeQueued(p []byte) (int, error) {
+	// Hold mu across the lazy init, the closed check and the send: Close
+	// also reads/flips these fields under mu, so none of it can
+	// interleave with Close and there is no window where we'd read a
+	// half-initialized queue or send on one Close has already torn down.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errRotatingFileClosed
+	}
+	if w.queue == nil {
+		w.queue = make(chan []byte, w.QueueSize)
+		w.closing = make(chan struct{})
+		w.queueDone = make(chan struct{})
+		go w.drainQueue()
+	}
+
+	cp := append([]byte(nil), p...)
+	select {
+	case w.queue <- cp:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// drainQueue applies queued writes in order via writeLocked, which does
+// not consult w.closed, so it keeps flushing everything Close finds
+// still buffered even though Close has already set w.closed. Once
+// w.closing fires it drains whatever remains without blocking and
+// returns.
+func (w *RotatingFile) drainQueue() {
+	defer close(w.queueDone)
+	for {
+		select {
+		case buf := <-w.queue:
+			w.mu.Lock()
+			w.writeLocked(buf)
+			w.mu.Unlock()
+		case <-w.closing:
+			for {
+				select {
+				case buf := <-w.queue:
+					w.mu.Lock()
+					w.writeLocked(buf)
+					w.mu.Unlock()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// DroppedCount returns the number of writes dropped so far because the
+// QueueSize channel was full. It is always zero when QueueSize is zero.
+func (w *RotatingFile) DroppedCount() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+func (w *RotatingFile) writeSync(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errRotatingFileClosed
+	}
+	return w.writeLocked(p)
+}
+
+// writeLocked opens the file if needed, rotates it if needed, and writes
+// p. Callers hold w.mu. Unlike writeSync, it does not check w.closed, so
+// drainQueue can use it to flush writes queued before Close even after
+// Close has flipped that flag.
+func (w *RotatingFile) writeLocked(p []byte) (int, error) {
+	if w.f == nil {
+		if err := w.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotateLocked(len(p)) {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFile) openLocked() error {
+	if dir := filepath.Dir(w.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(w.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.size = fi.Size()
+	w.openedAt = time.Now()
+	return nil
+}
+
+func (w *RotatingFile) shouldRotateLocked(n int) bool {
+	if w.MaxSize > 0 && w.size+int64(n) > w.MaxSize {
+		return true
+	}
+	switch w.Interval {
+	case Hourly:
+		return !sameHour(time.Now(), w.openedAt)
+	case Daily:
+		return !sameDay(time.Now(), w.openedAt)
+	}
+	return false
+}
+
+// sameDay reports whether a and b fall on the same calendar date in
+// their own location (Local, for the times this package deals in), so
+// Daily rotation lands on local midnight rather than the UTC epoch that
+// Time.Truncate(24*time.Hour) buckets on.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// sameHour reports whether a and b fall within the same calendar hour in
+// their own location, for the same reason sameDay compares calendar
+// dates instead of using Time.Truncate(time.Hour): in zones with a
+// sub-hour UTC offset, Truncate buckets at :30/:45 past the local hour
+// instead of on it.
+func sameHour(a, b time.Time) bool {
+	return sameDay(a, b) && a.Hour() == b.Hour()
+}
+
+// rotateLocked closes and renames the active file, kicking off background
+// compression and backup pruning, then reopens Path fresh. Callers hold
+// w.mu.
+func (w *RotatingFile) rotateLocked() error {
+	if w.f != nil {
+		w.f.Close()
+		w.f = nil
+	}
+
+	if _, err := os.Stat(w.Path); err == nil {
+		rotated := w.Path + "." + time.Now().Format("20060102T150405.000000000")
+		if err := os.Rename(w.Path, rotated); err != nil {
+			return err
+		}
+		if w.Compress {
+			// Compress, then prune, in that order on the same
+			// goroutine: pruning must not race compress for the same
+			// rotated file, and must not run until compress has
+			// replaced it with its ".gz" sibling.
+			go func() {
+				w.compress(rotated)
+				w.prune()
+			}()
+		} else {
+			go w.prune()
+		}
+	}
+
+	return w.openLocked()
+}
+
+func (w *RotatingFile) compress(path string) {
+	in, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		os.Remove(path + ".gz")
+		return
+	}
+	gz.Close()
+	out.Close()
+	os.Remove(path)
+}
+
+// prune removes the oldest rotated segments once there are more than
+// MaxBackups of them. A rotated file and its compressed ".gz" sibling are
+// the same logical segment, so they're deduped onto one timestamp key
+// before counting; that key also sorts lexically in rotation order,
+// since it's the Path prefix plus a fixed-width timestamp suffix.
+func (w *RotatingFile) prune() {
+	if w.MaxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(w.Path + ".*")
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool, len(matches))
+	var keys []string
+	for _, m := range matches {
+		key := strings.TrimSuffix(m, ".gz")
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) <= w.MaxBackups {
+		return
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys[:len(keys)-w.MaxBackups] {
+		os.Remove(key)
+		os.Remove(key + ".gz")
+	}
+}
+
+// Close marks w closed, rejecting any further writes (queued or
+// synchronous), flushes and stops the background queue drain (if
+// QueueSize was set), stops the SIGHUP watcher (if WatchSIGHUP was
+// called), and closes the underlying file. Calling Close more than once
+// is a no-op after the first call.
+func (w *RotatingFile) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	queueStarted := w.queue != nil
+	w.mu.Unlock()
+
+	if queueStarted {
+		// Signal the drain goroutine through w.closing instead of
+		// closing w.queue itself, so a stray close/send ordering bug
+		// here can never turn into a "send on closed channel" panic in
+		// writeQueued.
+		close(w.closing)
+		<-w.queueDone // wait for every write queued before Close to be flushed
+	}
+	if w.hup != nil {
+		signal.Stop(w.hup)
+		close(w.hup)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
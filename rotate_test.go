@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileQueuedWriteThenCloseFlushes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w := NewRotatingFile(path)
+	w.QueueSize = 64
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		if _, err := w.Write([]byte(fmt.Sprintf("line %d\n", i))); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if got := strings.Count(string(data), "\n"); got != n {
+		t.Fatalf("got %d flushed lines, want %d (contents: %q)", got, n, data)
+	}
+
+	if _, err := w.Write([]byte("after close\n")); err != errRotatingFileClosed {
+		t.Fatalf("Write after Close returned %v, want errRotatingFileClosed", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close returned %v, want nil", err)
+	}
+}
+
+func TestRotatingFileMaxSizeRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w := NewRotatingFile(path)
+	w.MaxSize = 10
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(matches) == 0 {
+		t.Fatalf("expected at least one rotated segment under %q, found none", path)
+	}
+}
+
+func TestRotatingFileDailyBoundaryIsLocalCalendarDay(t *testing.T) {
+	w := NewRotatingFile(filepath.Join(t.TempDir(), "app.log"))
+	w.Interval = Daily
+	w.openedAt = time.Now().Add(-25 * time.Hour)
+
+	if !w.shouldRotateLocked(1) {
+		t.Fatalf("shouldRotateLocked(Daily) = false across a >24h gap, want true")
+	}
+	w.openedAt = time.Now()
+	if w.shouldRotateLocked(1) {
+		t.Fatalf("shouldRotateLocked(Daily) = true for a file opened moments ago, want false")
+	}
+	now := time.Now()
+	w.openedAt = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).Add(-time.Nanosecond)
+	if !w.shouldRotateLocked(1) {
+		t.Fatalf("shouldRotateLocked(Daily) = false for a file opened just before local midnight, want true")
+	}
+}
+
+func TestRotatingFileHourlyBoundaryIsLocalClockHour(t *testing.T) {
+	w := NewRotatingFile(filepath.Join(t.TempDir(), "app.log"))
+	w.Interval = Hourly
+	w.openedAt = time.Now().Add(-61 * time.Minute)
+
+	if !w.shouldRotateLocked(1) {
+		t.Fatalf("shouldRotateLocked(Hourly) = false across a >60m gap, want true")
+	}
+	w.openedAt = time.Now()
+	if w.shouldRotateLocked(1) {
+		t.Fatalf("shouldRotateLocked(Hourly) = true for a file opened moments ago, want false")
+	}
+	now := time.Now()
+	w.openedAt = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.Local).Add(-time.Nanosecond)
+	if !w.shouldRotateLocked(1) {
+		t.Fatalf("shouldRotateLocked(Hourly) = false for a file opened just before the local hour, want true")
+	}
+}
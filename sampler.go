@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler decides whether a record at a given call site should be
+// written. It is consulted once per record, after caller information has
+// been resolved, so Allow always receives a real file:line even when the
+// Logger's flags would not otherwise print one; attaching any Sampler via
+// (*Logger).SetSampler forces that resolution.
+type Sampler interface {
+	Allow(level int, file string, line int) bool
+}
+
+// RateLimiter is a Sampler that token-bucket limits messages per level.
+// Levels with no configured rate are allowed unconditionally.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[int]*tokenBucket
+}
+
+// NewRateLimiter returns a RateLimiter with no levels configured; use
+// SetRate to configure one.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[int]*tokenBucket)}
+}
+
+// SetRate configures level to allow up to rate messages/sec, bursting up
+// to burst at once. Returns rl for chaining off NewRateLimiter.
+func (rl *RateLimiter) SetRate(level int, rate float64, burst int) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.buckets[level] = &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+	return rl
+}
+
+// Allow implements Sampler. file and line are accepted for interface
+// compatibility but RateLimiter only keys on level.
+func (rl *RateLimiter) Allow(level int, file string, line int) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b, ok := rl.buckets[level]
+	if !ok {
+		return true
+	}
+	return b.take()
+}
+
+// tokenBucket refills at rate tokens/sec, capped at burst, and is spent
+// one token per allowed message.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take() bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// CallSiteSampler is a Sampler that, for each distinct file:line, allows
+// the first First records through and then every Thereafter-th one after
+// that, so a hot line gets thinned out rather than silenced.
+type CallSiteSampler struct {
+	// First is how many records from a new call site are allowed before
+	// thinning begins.
+	First int
+	// Thereafter allows every Thereafter-th record once First has been
+	// exceeded; a value of 1 or less allows everything after First.
+	Thereafter int
+
+	mu   sync.Mutex
+	seen map[callSite]uint64
+}
+
+type callSite struct {
+	file string
+	line int
+}
+
+// Allow implements Sampler.
+func (s *CallSiteSampler) Allow(level int, file string, line int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen == nil {
+		s.seen = make(map[callSite]uint64)
+	}
+	key := callSite{file, line}
+	n := s.seen[key] + 1
+	s.seen[key] = n
+
+	if int(n) <= s.First {
+		return true
+	}
+	if s.Thereafter <= 1 {
+		return true
+	}
+	return (n-uint64(s.First))%uint64(s.Thereafter) == 0
+}